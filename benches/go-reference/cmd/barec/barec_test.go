@@ -0,0 +1,234 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	goparser "go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"testing"
+)
+
+func TestParseSchemaUserBare(t *testing.T) {
+	src, err := os.ReadFile("testdata/user.bare")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	schema, err := ParseSchema(string(src))
+	if err != nil {
+		t.Fatalf("ParseSchema: %v", err)
+	}
+	if len(schema.Enums) != 1 || schema.Enums[0].Name != "UserRole" {
+		t.Fatalf("Enums = %+v, want one UserRole enum", schema.Enums)
+	}
+	if len(schema.Structs) != 2 {
+		t.Fatalf("Structs = %+v, want 2 structs", schema.Structs)
+	}
+}
+
+func TestParseSchemaUnionsAndArrays(t *testing.T) {
+	const src = `
+type Circle struct {
+	radius: uint
+}
+
+type Square struct {
+	side: uint
+}
+
+type Shape (Circle | Square)
+
+type Widget struct {
+	label: optional<string>
+	tags: []string
+	fingerprint: [4]u8
+	shape: Shape
+}
+`
+	schema, err := ParseSchema(src)
+	if err != nil {
+		t.Fatalf("ParseSchema: %v", err)
+	}
+	if len(schema.Unions) != 1 {
+		t.Fatalf("Unions = %+v, want 1", schema.Unions)
+	}
+	union := schema.Unions[0]
+	if union.Name != "Shape" || len(union.Variants) != 2 {
+		t.Fatalf("union = %+v, want Shape(Circle|Square)", union)
+	}
+
+	var widget StructDecl
+	for _, s := range schema.Structs {
+		if s.Name == "Widget" {
+			widget = s
+		}
+	}
+	if widget.Name == "" {
+		t.Fatalf("Widget struct not found in %+v", schema.Structs)
+	}
+
+	byName := map[string]Field{}
+	for _, f := range widget.Fields {
+		byName[f.Name] = f
+	}
+
+	if ft := byName["label"].Type; ft.Name != "string" || !ft.Optional {
+		t.Errorf("label type = %+v, want optional string", ft)
+	}
+	if ft := byName["tags"].Type; ft.Name != "string" || !ft.Array || ft.ArrayLen != 0 {
+		t.Errorf("tags type = %+v, want unbounded []string", ft)
+	}
+	if ft := byName["fingerprint"].Type; ft.Name != "u8" || !ft.Array || ft.ArrayLen != 4 {
+		t.Errorf("fingerprint type = %+v, want [4]u8", ft)
+	}
+	if ft := byName["shape"].Type; ft.Name != "Shape" {
+		t.Errorf("shape type = %+v, want Shape", ft)
+	}
+}
+
+// TestGeneratedCodeTypechecks guards against the class of bug the review
+// round found in this generator: emitting Go that doesn't compile (an
+// optional<string> field calling MarshalBARE on a *string, or sibling
+// fields redeclaring the same v/err names at function scope). It type-checks
+// Generate's output against a minimal stand-in for the bare package's
+// exported surface, so a regression here fails `go test` instead of only
+// showing up when someone runs barec for real and tries to build the
+// result.
+func TestGeneratedCodeTypechecks(t *testing.T) {
+	const schemaSrc = `
+type Color enum {
+	RED
+	GREEN
+	BLUE
+}
+
+type Circle struct {
+	radius: uint
+}
+
+type Square struct {
+	side: uint
+}
+
+type Shape (Circle | Square)
+
+type Widget struct {
+	id: uint
+	label: optional<string>
+	tags: []string
+	fingerprint: [4]u8
+	color: Color
+	favorite: optional<Color>
+	shape: Shape
+	extra: optional<Circle>
+}
+`
+	schema, err := ParseSchema(schemaSrc)
+	if err != nil {
+		t.Fatalf("ParseSchema: %v", err)
+	}
+	generated, err := Generate("generated", schema)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	userSrc, err := os.ReadFile("testdata/user.bare")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	userSchema, err := ParseSchema(string(userSrc))
+	if err != nil {
+		t.Fatalf("ParseSchema(user.bare): %v", err)
+	}
+	userGenerated, err := Generate("generated", userSchema)
+	if err != nil {
+		t.Fatalf("Generate(user.bare): %v", err)
+	}
+
+	typecheckGenerated(t, "widget.go", generated)
+	typecheckGenerated(t, "user.go", userGenerated)
+}
+
+func typecheckGenerated(t *testing.T, filename string, src []byte) {
+	t.Helper()
+	fset := token.NewFileSet()
+
+	bareFile, err := goparser.ParseFile(fset, "bare.go", bareStubSrc, 0)
+	if err != nil {
+		t.Fatalf("parsing bare stub: %v", err)
+	}
+	bareConf := types.Config{Importer: importer.Default()}
+	barePkg, err := bareConf.Check("git.sr.ht/~sircmpwn/go-bare", fset, []*ast.File{bareFile}, nil)
+	if err != nil {
+		t.Fatalf("type-checking bare stub: %v", err)
+	}
+
+	genFile, err := goparser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+
+	conf := types.Config{Importer: stubImporter{barePkg}}
+	if _, err := conf.Check("generated", fset, []*ast.File{genFile}, nil); err != nil {
+		t.Fatalf("generated code does not type-check: %v\n%s", err, src)
+	}
+}
+
+// stubImporter resolves git.sr.ht/~sircmpwn/go-bare to the in-memory bare
+// stub and falls back to the real importer for everything else (fmt).
+type stubImporter struct {
+	bare *types.Package
+}
+
+func (i stubImporter) Import(path string) (*types.Package, error) {
+	if path == "git.sr.ht/~sircmpwn/go-bare" {
+		return i.bare, nil
+	}
+	return importer.Default().Import(path)
+}
+
+// bareStubSrc declares the same exported surface as
+// git.sr.ht/~sircmpwn/go-bare that generated code calls into, so
+// Generate's output can be type-checked without a network dependency.
+var bareStubSrc = []byte(`
+package bare
+
+type Writer struct{}
+type Reader struct{}
+
+func (w *Writer) WriteU8(v uint8) error     { return nil }
+func (w *Writer) WriteU16(v uint16) error   { return nil }
+func (w *Writer) WriteU32(v uint32) error   { return nil }
+func (w *Writer) WriteU64(v uint64) error   { return nil }
+func (w *Writer) WriteI8(v int8) error      { return nil }
+func (w *Writer) WriteI16(v int16) error    { return nil }
+func (w *Writer) WriteI32(v int32) error    { return nil }
+func (w *Writer) WriteI64(v int64) error    { return nil }
+func (w *Writer) WriteF32(v float32) error  { return nil }
+func (w *Writer) WriteF64(v float64) error  { return nil }
+func (w *Writer) WriteUint(v uint64) error  { return nil }
+func (w *Writer) WriteInt(v int64) error    { return nil }
+func (w *Writer) WriteBool(v bool) error    { return nil }
+func (w *Writer) WriteString(v string) error { return nil }
+func (w *Writer) WriteData(v []byte) error  { return nil }
+
+func (r *Reader) ReadU8() (uint8, error)      { return 0, nil }
+func (r *Reader) ReadU16() (uint16, error)    { return 0, nil }
+func (r *Reader) ReadU32() (uint32, error)    { return 0, nil }
+func (r *Reader) ReadU64() (uint64, error)    { return 0, nil }
+func (r *Reader) ReadI8() (int8, error)       { return 0, nil }
+func (r *Reader) ReadI16() (int16, error)     { return 0, nil }
+func (r *Reader) ReadI32() (int32, error)     { return 0, nil }
+func (r *Reader) ReadI64() (int64, error)     { return 0, nil }
+func (r *Reader) ReadF32() (float32, error)   { return 0, nil }
+func (r *Reader) ReadF64() (float64, error)   { return 0, nil }
+func (r *Reader) ReadUint() (uint64, error)   { return 0, nil }
+func (r *Reader) ReadInt() (int64, error)     { return 0, nil }
+func (r *Reader) ReadBool() (bool, error)     { return false, nil }
+func (r *Reader) ReadString() (string, error) { return "", nil }
+func (r *Reader) ReadData() ([]byte, error)   { return nil, nil }
+
+func Marshal(v interface{}) ([]byte, error) { return nil, nil }
+func Unmarshal(data []byte, v interface{}) error { return nil }
+`)
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// goPrimitive maps a BARE primitive name to its Go representation and the
+// Writer/Reader method suffix used to encode/decode it (WriteXxx / ReadXxx).
+var goPrimitive = map[string]struct {
+	goType string
+	suffix string
+}{
+	"uint":   {"uint64", "Uint"},
+	"int":    {"int64", "Int"},
+	"u8":     {"uint8", "U8"},
+	"u16":    {"uint16", "U16"},
+	"u32":    {"uint32", "U32"},
+	"u64":    {"uint64", "U64"},
+	"i8":     {"int8", "I8"},
+	"i16":    {"int16", "I16"},
+	"i32":    {"int32", "I32"},
+	"i64":    {"int64", "I64"},
+	"f32":    {"float32", "F32"},
+	"f64":    {"float64", "F64"},
+	"bool":   {"bool", "Bool"},
+	"string": {"string", "String"},
+	"data":   {"[]byte", "Data"},
+}
+
+// names bundles the type names declared in a schema so the marshal/
+// unmarshal emitters can tell a primitive, enum, union, or plain struct
+// field apart from one another.
+type names struct {
+	enums  map[string]bool
+	unions map[string]bool
+}
+
+// Generate emits Go source implementing Marshal/Unmarshal for every struct in
+// schema, dispatched to by bare.Marshal/bare.Unmarshal's Marshalable/
+// Unmarshalable detection (their method names and signatures must match
+// bare.Marshalable/bare.Unmarshalable exactly, or bare falls back to its
+// normal reflection-based encoding and never calls the generated code), plus
+// a const block per enum and marshal/unmarshal functions per tagged union.
+// The output is gofmt'd before being returned.
+func Generate(pkg string, schema *Schema) ([]byte, error) {
+	n := names{
+		enums:  make(map[string]bool, len(schema.Enums)),
+		unions: make(map[string]bool, len(schema.Unions)),
+	}
+	for _, e := range schema.Enums {
+		n.enums[e.Name] = true
+	}
+	for _, u := range schema.Unions {
+		n.unions[u.Name] = true
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by barec. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	if len(schema.Unions) > 0 {
+		// fmt is only used by the union codegen below (unknown-variant/tag
+		// error messages); importing it unconditionally breaks go vet's
+		// unused-import check for any schema with no unions.
+		fmt.Fprintf(&buf, "import (\n\t\"fmt\"\n\n\t\"git.sr.ht/~sircmpwn/go-bare\"\n)\n\n")
+	} else {
+		fmt.Fprintf(&buf, "import (\n\t\"git.sr.ht/~sircmpwn/go-bare\"\n)\n\n")
+	}
+
+	for _, e := range schema.Enums {
+		writeEnum(&buf, e)
+	}
+	for _, s := range schema.Structs {
+		writeStruct(&buf, s, n)
+	}
+	for _, u := range schema.Unions {
+		writeUnion(&buf, u)
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+func writeEnum(buf *bytes.Buffer, e EnumDecl) {
+	fmt.Fprintf(buf, "type %s uint\n\nconst (\n", e.Name)
+	for _, v := range e.Values {
+		fmt.Fprintf(buf, "\t%s %s = %d\n", v.Name, e.Name, v.Value)
+	}
+	fmt.Fprintf(buf, ")\n\n")
+}
+
+// writeUnion emits a marker interface satisfied by every variant (each
+// variant already has a Marshal method from writeStruct) plus the
+// MarshalXBARE/UnmarshalXBARE pair that prefixes/reads the tag byte
+// selecting which variant is present. A union can't implement
+// Marshal/Unmarshal as plain interface methods the way a struct does:
+// encoding needs to write the tag *before* the variant, and decoding needs
+// the tag to know which concrete type to allocate in the first place. Struct
+// fields of this union type call MarshalXBARE/UnmarshalXBARE directly
+// (writeScalarMarshal/writeScalarUnmarshal below), so the tag dispatch never
+// goes through bare's own reflection-based bare.Union/RegisterUnion path.
+func writeUnion(buf *bytes.Buffer, u UnionDecl) {
+	fmt.Fprintf(buf, "type %s interface {\n\tis%s()\n}\n\n", u.Name, u.Name)
+	for _, variant := range u.Variants {
+		fmt.Fprintf(buf, "func (*%s) is%s() {}\n\n", variant, u.Name)
+	}
+
+	fmt.Fprintf(buf, "func Marshal%sBARE(w *bare.Writer, v %s) error {\n\tswitch t := v.(type) {\n", u.Name, u.Name)
+	for i, variant := range u.Variants {
+		fmt.Fprintf(buf, "\tcase *%s:\n\t\tif err := w.WriteU8(%d); err != nil {\n\t\t\treturn err\n\t\t}\n\t\treturn t.Marshal(w)\n", variant, i)
+	}
+	fmt.Fprintf(buf, "\tdefault:\n\t\treturn fmt.Errorf(\"%s: unknown variant %%T\", v)\n\t}\n}\n\n", u.Name)
+
+	fmt.Fprintf(buf, "func Unmarshal%sBARE(r *bare.Reader) (%s, error) {\n\ttag, err := r.ReadU8()\n\tif err != nil {\n\t\treturn nil, err\n\t}\n\tswitch tag {\n", u.Name, u.Name)
+	for i, variant := range u.Variants {
+		fmt.Fprintf(buf, "\tcase %d:\n\t\tv := &%s{}\n\t\tif err := v.Unmarshal(r); err != nil {\n\t\t\treturn nil, err\n\t\t}\n\t\treturn v, nil\n", i, variant)
+	}
+	fmt.Fprintf(buf, "\tdefault:\n\t\treturn nil, fmt.Errorf(\"%s: unknown tag %%d\", tag)\n\t}\n}\n\n", u.Name)
+}
+
+func writeStruct(buf *bytes.Buffer, s StructDecl, n names) {
+	fmt.Fprintf(buf, "type %s struct {\n", s.Name)
+	for _, f := range s.Fields {
+		fmt.Fprintf(buf, "\t%s %s\n", exported(f.Name), goFieldType(f.Type, n))
+	}
+	fmt.Fprintf(buf, "}\n\n")
+
+	fmt.Fprintf(buf, "func (t *%s) Marshal(w *bare.Writer) error {\n", s.Name)
+	for _, f := range s.Fields {
+		writeFieldMarshal(buf, f, n)
+	}
+	fmt.Fprintf(buf, "\treturn nil\n}\n\n")
+
+	fmt.Fprintf(buf, "func (t *%s) Unmarshal(r *bare.Reader) error {\n", s.Name)
+	for _, f := range s.Fields {
+		writeFieldUnmarshal(buf, f, n)
+	}
+	fmt.Fprintf(buf, "\treturn nil\n}\n\n")
+}
+
+// elementGoType returns the Go type for a single element of t, ignoring
+// t.Array/t.Optional (the caller wraps those on).
+func elementGoType(t FieldType, n names) string {
+	if prim, ok := goPrimitive[t.Name]; ok {
+		return prim.goType
+	}
+	if n.unions[t.Name] {
+		return t.Name
+	}
+	return t.Name
+}
+
+func goFieldType(t FieldType, n names) string {
+	base := elementGoType(t, n)
+	if t.Array {
+		if t.ArrayLen > 0 {
+			base = fmt.Sprintf("[%d]%s", t.ArrayLen, base)
+		} else {
+			base = "[]" + base
+		}
+	}
+	if t.Optional && !n.unions[t.Name] {
+		// Unions are already nil-able interfaces; everything else needs an
+		// explicit pointer to represent "absent".
+		return "*" + base
+	}
+	return base
+}
+
+func isPrimitive(name string) bool {
+	_, ok := goPrimitive[name]
+	return ok
+}
+
+// writeScalarMarshal emits the single statement that writes one value of
+// the given (non-optional, non-array) field type, where expr is the Go
+// expression holding that value.
+func writeScalarMarshal(buf *bytes.Buffer, t FieldType, n names, expr string) {
+	switch {
+	case n.enums[t.Name]:
+		fmt.Fprintf(buf, "\tif err := w.WriteUint(uint64(%s)); err != nil {\n\t\treturn err\n\t}\n", expr)
+	case n.unions[t.Name]:
+		fmt.Fprintf(buf, "\tif err := Marshal%sBARE(w, %s); err != nil {\n\t\treturn err\n\t}\n", t.Name, expr)
+	case isPrimitive(t.Name):
+		prim := goPrimitive[t.Name]
+		fmt.Fprintf(buf, "\tif err := w.Write%s(%s); err != nil {\n\t\treturn err\n\t}\n", prim.suffix, expr)
+	default:
+		fmt.Fprintf(buf, "\tif err := %s.Marshal(w); err != nil {\n\t\treturn err\n\t}\n", expr)
+	}
+}
+
+// writeScalarUnmarshal emits the statements that read one value of the
+// given (non-optional, non-array) field type and assign it to the Go
+// expression assignTo. For a plain nested-struct type, assignTo may either
+// already be a pointer that needs allocating (an optional<Struct> field,
+// allocPointer true) or an addressable struct value that can be decoded
+// in place via Go's automatic addressing of pointer-receiver methods (a
+// plain field or array element, allocPointer false) — conflating the two
+// either assigns a *T into a T-typed slot or leaves an optional field's
+// pointer nil, so the caller must say which one assignTo is.
+func writeScalarUnmarshal(buf *bytes.Buffer, t FieldType, n names, assignTo string, allocPointer bool) {
+	switch {
+	case n.enums[t.Name]:
+		fmt.Fprintf(buf, "\tv, err := r.ReadUint()\n\tif err != nil {\n\t\treturn err\n\t}\n\t%s = %s(v)\n", assignTo, t.Name)
+	case n.unions[t.Name]:
+		fmt.Fprintf(buf, "\tv, err := Unmarshal%sBARE(r)\n\tif err != nil {\n\t\treturn err\n\t}\n\t%s = v\n", t.Name, assignTo)
+	case isPrimitive(t.Name):
+		prim := goPrimitive[t.Name]
+		fmt.Fprintf(buf, "\tv, err := r.Read%s()\n\tif err != nil {\n\t\treturn err\n\t}\n\t%s = v\n", prim.suffix, assignTo)
+	case allocPointer:
+		fmt.Fprintf(buf, "\t%s = &%s{}\n\tif err := %s.Unmarshal(r); err != nil {\n\t\treturn err\n\t}\n", assignTo, t.Name, assignTo)
+	default:
+		fmt.Fprintf(buf, "\tif err := %s.Unmarshal(r); err != nil {\n\t\treturn err\n\t}\n", assignTo)
+	}
+}
+
+func writeFieldMarshal(buf *bytes.Buffer, f Field, n names) {
+	name := exported(f.Name)
+	expr := fmt.Sprintf("t.%s", name)
+
+	if f.Type.Array {
+		elem := f.Type
+		elem.Array = false
+		if f.Type.ArrayLen == 0 {
+			fmt.Fprintf(buf, "\tif err := w.WriteUint(uint64(len(%s))); err != nil {\n\t\treturn err\n\t}\n", expr)
+		}
+		fmt.Fprintf(buf, "\tfor i := range %s {\n", expr)
+		writeScalarMarshal(buf, elem, n, fmt.Sprintf("%s[i]", expr))
+		fmt.Fprintf(buf, "\t}\n")
+		return
+	}
+
+	if f.Type.Optional {
+		// Unions need the same presence bool as every other optional field:
+		// Marshal<Union>BARE has no "absent" tag of its own (it switches on
+		// the concrete type and errors on an unrecognized one), so a nil
+		// optional<Union> would fail to marshal at all without one.
+		fmt.Fprintf(buf, "\tif err := w.WriteBool(%s != nil); err != nil {\n\t\treturn err\n\t}\n", expr)
+		fmt.Fprintf(buf, "\tif %s != nil {\n", expr)
+		valueExpr := expr
+		if isPrimitive(f.Type.Name) || n.enums[f.Type.Name] {
+			valueExpr = "*" + expr
+		}
+		writeScalarMarshal(buf, f.Type, n, valueExpr)
+		fmt.Fprintf(buf, "\t}\n")
+		return
+	}
+
+	writeScalarMarshal(buf, f.Type, n, expr)
+}
+
+// writeFieldUnmarshal emits the statements that decode one field. Every
+// branch below may declare a locally-named v/err/present/n; since several
+// scalar/optional/array fields of a struct can share the same Go types, and
+// a repeated `v, err := ...` at the same block scope with differing types
+// fails to compile (and even same-typed repeats fail without a new
+// variable on the left of :=), each field's statements are wrapped in
+// their own { } block so the names never collide across fields.
+func writeFieldUnmarshal(buf *bytes.Buffer, f Field, n names) {
+	name := exported(f.Name)
+	expr := fmt.Sprintf("t.%s", name)
+
+	fmt.Fprintf(buf, "\t{\n")
+	defer fmt.Fprintf(buf, "\t}\n")
+
+	if f.Type.Array {
+		elem := f.Type
+		elem.Array = false
+		elemType := elementGoType(elem, n)
+		if f.Type.ArrayLen > 0 {
+			fmt.Fprintf(buf, "\tfor i := range %s {\n", expr)
+			writeScalarUnmarshal(buf, elem, n, fmt.Sprintf("%s[i]", expr), false)
+			fmt.Fprintf(buf, "\t}\n")
+		} else {
+			fmt.Fprintf(buf, "\tn, err := r.ReadUint()\n\tif err != nil {\n\t\treturn err\n\t}\n")
+			fmt.Fprintf(buf, "\t%s = make([]%s, n)\n\tfor i := range %s {\n", expr, elemType, expr)
+			writeScalarUnmarshal(buf, elem, n, fmt.Sprintf("%s[i]", expr), false)
+			fmt.Fprintf(buf, "\t}\n")
+		}
+		return
+	}
+
+	if f.Type.Optional {
+		fmt.Fprintf(buf, "\tpresent, err := r.ReadBool()\n\tif err != nil {\n\t\treturn err\n\t}\n")
+		fmt.Fprintf(buf, "\tif present {\n")
+		if isPrimitive(f.Type.Name) || n.enums[f.Type.Name] {
+			goType := elementGoType(f.Type, n)
+			fmt.Fprintf(buf, "\t\tvar ev %s\n", goType)
+			writeScalarUnmarshal(buf, f.Type, n, "ev", false)
+			fmt.Fprintf(buf, "\t\t%s = &ev\n", expr)
+		} else if n.unions[f.Type.Name] {
+			// A union's Go field type is already a nilable interface (see
+			// goFieldType), so there's nothing to allocate here; Unmarshal<Union>BARE
+			// returns the concrete variant directly.
+			writeScalarUnmarshal(buf, f.Type, n, expr, false)
+		} else {
+			// f.Type's Go field type is a pointer here (goFieldType adds one
+			// for any optional non-union type), so the struct itself must be
+			// allocated before Unmarshal has anything to decode into.
+			writeScalarUnmarshal(buf, f.Type, n, expr, true)
+		}
+		fmt.Fprintf(buf, "\t}\n")
+		return
+	}
+
+	writeScalarUnmarshal(buf, f.Type, n, expr, false)
+}
+
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
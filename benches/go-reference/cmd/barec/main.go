@@ -0,0 +1,50 @@
+// Command barec generates reflection-free Marshal/Unmarshal methods from a
+// .bare schema file. The generated methods satisfy bare.Marshalable/
+// bare.Unmarshalable, so a generated type is a drop-in replacement for a
+// plain reflection-encoded struct passed to bare.Marshal/bare.Unmarshal.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	schemaPath := flag.String("schema", "", "path to the .bare schema file")
+	pkg := flag.String("pkg", "main", "package name for the generated file")
+	out := flag.String("out", "", "output path (default: stdout)")
+	flag.Parse()
+
+	if *schemaPath == "" {
+		fmt.Fprintln(os.Stderr, "barec: -schema is required")
+		os.Exit(1)
+	}
+
+	src, err := os.ReadFile(*schemaPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "barec: %s\n", err)
+		os.Exit(1)
+	}
+
+	schema, err := ParseSchema(string(src))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "barec: %s\n", err)
+		os.Exit(1)
+	}
+
+	generated, err := Generate(*pkg, schema)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "barec: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(generated)
+		return
+	}
+	if err := os.WriteFile(*out, generated, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "barec: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,188 @@
+// Code generated by barec. DO NOT EDIT.
+
+package roundtrip
+
+import (
+	"fmt"
+
+	"git.sr.ht/~sircmpwn/go-bare"
+)
+
+type Color uint
+
+const (
+	RED   Color = 0
+	GREEN Color = 1
+	BLUE  Color = 2
+)
+
+type Circle struct {
+	Radius uint64
+}
+
+func (t *Circle) Marshal(w *bare.Writer) error {
+	if err := w.WriteUint(t.Radius); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *Circle) Unmarshal(r *bare.Reader) error {
+	{
+		v, err := r.ReadUint()
+		if err != nil {
+			return err
+		}
+		t.Radius = v
+	}
+	return nil
+}
+
+type Square struct {
+	Side uint64
+}
+
+func (t *Square) Marshal(w *bare.Writer) error {
+	if err := w.WriteUint(t.Side); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *Square) Unmarshal(r *bare.Reader) error {
+	{
+		v, err := r.ReadUint()
+		if err != nil {
+			return err
+		}
+		t.Side = v
+	}
+	return nil
+}
+
+type Widget struct {
+	Id    uint64
+	Label *string
+	Color Color
+	Shape Shape
+}
+
+func (t *Widget) Marshal(w *bare.Writer) error {
+	if err := w.WriteUint(t.Id); err != nil {
+		return err
+	}
+	if err := w.WriteBool(t.Label != nil); err != nil {
+		return err
+	}
+	if t.Label != nil {
+		if err := w.WriteString(*t.Label); err != nil {
+			return err
+		}
+	}
+	if err := w.WriteUint(uint64(t.Color)); err != nil {
+		return err
+	}
+	if err := w.WriteBool(t.Shape != nil); err != nil {
+		return err
+	}
+	if t.Shape != nil {
+		if err := MarshalShapeBARE(w, t.Shape); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Widget) Unmarshal(r *bare.Reader) error {
+	{
+		v, err := r.ReadUint()
+		if err != nil {
+			return err
+		}
+		t.Id = v
+	}
+	{
+		present, err := r.ReadBool()
+		if err != nil {
+			return err
+		}
+		if present {
+			var ev string
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			ev = v
+			t.Label = &ev
+		}
+	}
+	{
+		v, err := r.ReadUint()
+		if err != nil {
+			return err
+		}
+		t.Color = Color(v)
+	}
+	{
+		present, err := r.ReadBool()
+		if err != nil {
+			return err
+		}
+		if present {
+			v, err := UnmarshalShapeBARE(r)
+			if err != nil {
+				return err
+			}
+			t.Shape = v
+		}
+	}
+	return nil
+}
+
+type Shape interface {
+	isShape()
+}
+
+func (*Circle) isShape() {}
+
+func (*Square) isShape() {}
+
+func MarshalShapeBARE(w *bare.Writer, v Shape) error {
+	switch t := v.(type) {
+	case *Circle:
+		if err := w.WriteU8(0); err != nil {
+			return err
+		}
+		return t.Marshal(w)
+	case *Square:
+		if err := w.WriteU8(1); err != nil {
+			return err
+		}
+		return t.Marshal(w)
+	default:
+		return fmt.Errorf("Shape: unknown variant %T", v)
+	}
+}
+
+func UnmarshalShapeBARE(r *bare.Reader) (Shape, error) {
+	tag, err := r.ReadU8()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case 0:
+		v := &Circle{}
+		if err := v.Unmarshal(r); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case 1:
+		v := &Square{}
+		if err := v.Unmarshal(r); err != nil {
+			return nil, err
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("Shape: unknown tag %d", tag)
+	}
+}
@@ -0,0 +1,95 @@
+package roundtrip
+
+import (
+	"testing"
+
+	"git.sr.ht/~sircmpwn/go-bare"
+)
+
+// These tests exercise generated.go (produced by `go run ./cmd/barec -schema
+// cmd/barec/testdata/roundtrip.bare -pkg roundtrip` from the go-reference
+// module root) against the real go-bare module,
+// not a stand-in stub. They guard against bare.Marshal/bare.Unmarshal
+// silently falling back to reflection instead of calling the generated
+// Marshal/Unmarshal methods, and against optional<Union> fields failing to
+// round-trip when absent.
+
+func label(s string) *string { return &s }
+
+func TestWidgetRoundTripWithUnionVariant(t *testing.T) {
+	in := Widget{
+		Id:    7,
+		Label: label("hello"),
+		Color: GREEN,
+		Shape: &Circle{Radius: 42},
+	}
+
+	data, err := bare.Marshal(&in)
+	if err != nil {
+		t.Fatalf("bare.Marshal: %v", err)
+	}
+
+	var out Widget
+	if err := bare.Unmarshal(data, &out); err != nil {
+		t.Fatalf("bare.Unmarshal: %v", err)
+	}
+
+	if out.Id != in.Id || out.Color != in.Color {
+		t.Fatalf("round-tripped Id/Color = %+v, want %+v", out, in)
+	}
+	if out.Label == nil || *out.Label != *in.Label {
+		t.Fatalf("round-tripped Label = %v, want %v", out.Label, in.Label)
+	}
+	circle, ok := out.Shape.(*Circle)
+	if !ok {
+		t.Fatalf("round-tripped Shape = %T, want *Circle", out.Shape)
+	}
+	if circle.Radius != 42 {
+		t.Errorf("round-tripped Circle.Radius = %d, want 42", circle.Radius)
+	}
+}
+
+func TestWidgetRoundTripOtherUnionVariant(t *testing.T) {
+	in := Widget{Id: 8, Color: RED, Shape: &Square{Side: 9}}
+
+	data, err := bare.Marshal(&in)
+	if err != nil {
+		t.Fatalf("bare.Marshal: %v", err)
+	}
+
+	var out Widget
+	if err := bare.Unmarshal(data, &out); err != nil {
+		t.Fatalf("bare.Unmarshal: %v", err)
+	}
+	square, ok := out.Shape.(*Square)
+	if !ok {
+		t.Fatalf("round-tripped Shape = %T, want *Square", out.Shape)
+	}
+	if square.Side != 9 {
+		t.Errorf("round-tripped Square.Side = %d, want 9", square.Side)
+	}
+}
+
+// TestWidgetRoundTripNilOptionalUnion guards against the bug where a nil
+// optional<Union> field had no presence bool at all: MarshalShapeBARE has no
+// "absent" tag of its own, so marshaling a nil Shape used to fail with
+// "Shape: unknown variant <nil>" for every widget without one.
+func TestWidgetRoundTripNilOptionalUnion(t *testing.T) {
+	in := Widget{Id: 9, Color: BLUE, Shape: nil}
+
+	data, err := bare.Marshal(&in)
+	if err != nil {
+		t.Fatalf("bare.Marshal: %v", err)
+	}
+
+	var out Widget
+	if err := bare.Unmarshal(data, &out); err != nil {
+		t.Fatalf("bare.Unmarshal: %v", err)
+	}
+	if out.Shape != nil {
+		t.Errorf("round-tripped Shape = %v, want nil", out.Shape)
+	}
+	if out.Label != nil {
+		t.Errorf("round-tripped Label = %v, want nil", out.Label)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// This file implements a parser for the subset of the upstream BARE schema
+// language (https://baremessages.org) that the benchmarks in this repo
+// need: struct, enum, and tagged union declarations, primitive scalar
+// fields, optional<T> fields, and fixed-size/unbounded array fields. It
+// does not attempt maps yet.
+
+// FieldType is the type of a struct field: either a BARE primitive
+// ("uint", "string", "data", "bool", ...) or the name of another declared
+// type (struct, enum, or union) in the same schema.
+//
+// Array and ArrayLen describe a "[N]T" (fixed-size, ArrayLen > 0) or "[]T"
+// (unbounded, ArrayLen == 0) field; in both cases Name is the element
+// type's name, not the array's.
+type FieldType struct {
+	Name     string
+	Optional bool
+	Array    bool
+	ArrayLen int
+}
+
+// Field is a single named field of a struct declaration.
+type Field struct {
+	Name string
+	Type FieldType
+}
+
+// StructDecl is a `type Name struct { ... }` declaration.
+type StructDecl struct {
+	Name   string
+	Fields []Field
+}
+
+// EnumValue is one member of an enum declaration, in declaration order.
+type EnumValue struct {
+	Name  string
+	Value int
+}
+
+// EnumDecl is a `type Name enum { ... }` declaration.
+type EnumDecl struct {
+	Name   string
+	Values []EnumValue
+}
+
+// UnionDecl is a `type Name (A | B | C)` tagged union declaration. Variants
+// are encoded with a uint8 tag equal to their index in this slice.
+type UnionDecl struct {
+	Name     string
+	Variants []string
+}
+
+// Schema is the full set of declarations parsed from a .bare file.
+type Schema struct {
+	Structs []StructDecl
+	Enums   []EnumDecl
+	Unions  []UnionDecl
+}
+
+var primitiveTypes = map[string]bool{
+	"uint": true, "int": true, "u8": true, "u16": true, "u32": true, "u64": true,
+	"i8": true, "i16": true, "i32": true, "i64": true, "f32": true, "f64": true,
+	"bool": true, "string": true, "data": true,
+}
+
+// ParseSchema parses the contents of a .bare schema file.
+func ParseSchema(src string) (*Schema, error) {
+	tokens := tokenize(src)
+	p := &parser{tokens: tokens}
+	schema := &Schema{}
+
+	for !p.done() {
+		if err := p.parseDecl(schema); err != nil {
+			return nil, err
+		}
+	}
+	return schema, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *parser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *parser) expect(tok string) error {
+	got := p.next()
+	if got != tok {
+		return fmt.Errorf("barec: expected %q, got %q", tok, got)
+	}
+	return nil
+}
+
+func (p *parser) parseDecl(schema *Schema) error {
+	if err := p.expect("type"); err != nil {
+		return err
+	}
+	name := p.next()
+
+	// A union declaration opens straight into parens: `type Name (A | B)`.
+	if p.peek() == "(" {
+		decl, err := p.parseUnion(name)
+		if err != nil {
+			return err
+		}
+		schema.Unions = append(schema.Unions, decl)
+		return nil
+	}
+
+	kind := p.next()
+	switch kind {
+	case "struct":
+		decl, err := p.parseStruct(name)
+		if err != nil {
+			return err
+		}
+		schema.Structs = append(schema.Structs, decl)
+	case "enum":
+		decl, err := p.parseEnum(name)
+		if err != nil {
+			return err
+		}
+		schema.Enums = append(schema.Enums, decl)
+	default:
+		return fmt.Errorf("barec: unsupported declaration kind %q for type %q", kind, name)
+	}
+	return nil
+}
+
+func (p *parser) parseStruct(name string) (StructDecl, error) {
+	decl := StructDecl{Name: name}
+	if err := p.expect("{"); err != nil {
+		return decl, err
+	}
+	for p.peek() != "}" {
+		fieldName := p.next()
+		if err := p.expect(":"); err != nil {
+			return decl, err
+		}
+		ft, err := p.parseFieldType()
+		if err != nil {
+			return decl, err
+		}
+		decl.Fields = append(decl.Fields, Field{Name: fieldName, Type: ft})
+	}
+	p.next() // consume "}"
+	return decl, nil
+}
+
+func (p *parser) parseFieldType() (FieldType, error) {
+	tok := p.next()
+
+	if tok == "optional" {
+		if err := p.expect("<"); err != nil {
+			return FieldType{}, err
+		}
+		inner, err := p.parseFieldType()
+		if err != nil {
+			return FieldType{}, err
+		}
+		if err := p.expect(">"); err != nil {
+			return FieldType{}, err
+		}
+		inner.Optional = true
+		return inner, nil
+	}
+
+	if tok == "[" {
+		length := 0
+		if p.peek() != "]" {
+			n, err := strconv.Atoi(p.next())
+			if err != nil {
+				return FieldType{}, fmt.Errorf("barec: invalid array length: %w", err)
+			}
+			length = n
+		}
+		if err := p.expect("]"); err != nil {
+			return FieldType{}, err
+		}
+		elem, err := p.parseFieldType()
+		if err != nil {
+			return FieldType{}, err
+		}
+		elem.Array = true
+		elem.ArrayLen = length
+		return elem, nil
+	}
+
+	return FieldType{Name: tok}, nil
+}
+
+func (p *parser) parseEnum(name string) (EnumDecl, error) {
+	decl := EnumDecl{Name: name}
+	if err := p.expect("{"); err != nil {
+		return decl, err
+	}
+	value := 0
+	for p.peek() != "}" {
+		decl.Values = append(decl.Values, EnumValue{Name: p.next(), Value: value})
+		value++
+	}
+	p.next() // consume "}"
+	return decl, nil
+}
+
+func (p *parser) parseUnion(name string) (UnionDecl, error) {
+	decl := UnionDecl{Name: name}
+	if err := p.expect("("); err != nil {
+		return decl, err
+	}
+	for {
+		decl.Variants = append(decl.Variants, p.next())
+		if p.peek() == "|" {
+			p.next()
+			continue
+		}
+		break
+	}
+	if err := p.expect(")"); err != nil {
+		return decl, err
+	}
+	return decl, nil
+}
+
+// tokenize splits a .bare schema into the small set of tokens parseDecl
+// understands: identifiers/keywords and the punctuation { } : < > [ ] ( ) |.
+func tokenize(src string) []string {
+	var tokens []string
+	var cur strings.Builder
+
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+
+	for _, r := range src {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case strings.ContainsRune("{}:<>[]()|", r):
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding"
+	"reflect"
+	"sync"
+
+	"git.sr.ht/~sircmpwn/go-bare"
+)
+
+// binaryFieldInfo describes the fields of a struct type that should be routed
+// through encoding.BinaryMarshaler/BinaryUnmarshaler instead of bare's normal
+// reflection-based encoding. It is computed once per type and cached in
+// typeCache so repeated Marshal/Unmarshal calls skip the analysis.
+type binaryFieldInfo struct {
+	shadow reflect.Type
+	fields []int
+}
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+	typeCache sync.Map // reflect.Type -> *binaryFieldInfo
+)
+
+// analyzeBinaryFields inspects t (which must be a struct type) for fields
+// whose type implements encoding.BinaryMarshaler and whose pointer implements
+// encoding.BinaryUnmarshaler, and builds a shadow struct type with those
+// fields replaced by []byte so the result can be handed to bare.Marshal
+// unchanged. The analysis result is cached per type.
+func analyzeBinaryFields(t reflect.Type) *binaryFieldInfo {
+	if cached, ok := typeCache.Load(t); ok {
+		return cached.(*binaryFieldInfo)
+	}
+
+	var fields []int
+	shadowFields := make([]reflect.StructField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		shadowFields[i] = f
+		if f.Type.Implements(binaryMarshalerType) && reflect.PtrTo(f.Type).Implements(binaryUnmarshalerType) {
+			fields = append(fields, i)
+			shadowFields[i].Type = reflect.TypeOf([]byte(nil))
+		}
+	}
+
+	info := &binaryFieldInfo{fields: fields}
+	if len(fields) > 0 {
+		info.shadow = reflect.StructOf(shadowFields)
+	}
+	actual, _ := typeCache.LoadOrStore(t, info)
+	return actual.(*binaryFieldInfo)
+}
+
+// binaryShadowValue builds the []byte-substituted shadow value for v, or
+// returns the zero Value if v has no BinaryMarshaler fields and can be
+// passed to bare unchanged.
+func binaryShadowValue(rv reflect.Value) (reflect.Value, error) {
+	info := analyzeBinaryFields(rv.Type())
+	if len(info.fields) == 0 {
+		return reflect.Value{}, nil
+	}
+
+	shadow := reflect.New(info.shadow).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		if isBinaryField(info.fields, i) {
+			marshaler := rv.Field(i).Interface().(encoding.BinaryMarshaler)
+			data, err := marshaler.MarshalBinary()
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			shadow.Field(i).SetBytes(data)
+			continue
+		}
+		shadow.Field(i).Set(rv.Field(i))
+	}
+	return shadow, nil
+}
+
+// MarshalValue marshals v with bare.Marshal, except that any field
+// implementing encoding.BinaryMarshaler is first run through MarshalBinary
+// and stored as a BARE data block. This is the escape hatch that lets types
+// such as time.Time or big.Int ride along in a bare-encoded struct without a
+// hand-written codec.
+func MarshalValue(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return bare.Marshal(v)
+	}
+
+	shadow, err := binaryShadowValue(rv)
+	if err != nil {
+		return nil, err
+	}
+	if !shadow.IsValid() {
+		return bare.Marshal(v)
+	}
+	return bare.Marshal(shadow.Addr().Interface())
+}
+
+// MarshalValueWriter is the Writer-based counterpart to MarshalValue, for
+// callers on the streaming-write path exercised by bare.MarshalWriter.
+func MarshalValueWriter(w *bare.Writer, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return bare.MarshalWriter(w, v)
+	}
+
+	shadow, err := binaryShadowValue(rv)
+	if err != nil {
+		return err
+	}
+	if !shadow.IsValid() {
+		return bare.MarshalWriter(w, v)
+	}
+	return bare.MarshalWriter(w, shadow.Addr().Interface())
+}
+
+// UnmarshalValue is the symmetric counterpart to MarshalValue: it decodes
+// into v via bare.Unmarshal and then reconstitutes any BinaryMarshaler field
+// from its BARE data block via UnmarshalBinary.
+func UnmarshalValue(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return bare.Unmarshal(data, v)
+	}
+	rv = rv.Elem()
+
+	info := analyzeBinaryFields(rv.Type())
+	if len(info.fields) == 0 {
+		return bare.Unmarshal(data, v)
+	}
+
+	shadow := reflect.New(info.shadow)
+	if err := bare.Unmarshal(data, shadow.Interface()); err != nil {
+		return err
+	}
+	shadow = shadow.Elem()
+
+	for i := 0; i < rv.NumField(); i++ {
+		if isBinaryField(info.fields, i) {
+			unmarshaler := rv.Field(i).Addr().Interface().(encoding.BinaryUnmarshaler)
+			if err := unmarshaler.UnmarshalBinary(shadow.Field(i).Bytes()); err != nil {
+				return err
+			}
+			continue
+		}
+		rv.Field(i).Set(shadow.Field(i))
+	}
+
+	return nil
+}
+
+func isBinaryField(fields []int, i int) bool {
+	for _, f := range fields {
+		if f == i {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"reflect"
+	"sync"
+
+	"git.sr.ht/~sircmpwn/go-bare"
+)
+
+// WriterPool hands out *bare.Writer instances bound to a reusable buffer so
+// that repeated Marshal-style calls (e.g. once per request in a server loop)
+// don't pay for a fresh bytes.Buffer allocation every time.
+type WriterPool struct {
+	pool sync.Pool
+}
+
+// PooledWriter is a *bare.Writer paired with the buffer it writes into. Call
+// Buf.Bytes() after writing to read back the encoded form.
+type PooledWriter struct {
+	Writer *bare.Writer
+	Buf    *bytes.Buffer
+}
+
+// NewWriterPool returns an empty WriterPool.
+func NewWriterPool() *WriterPool {
+	return &WriterPool{
+		pool: sync.Pool{
+			New: func() interface{} {
+				buf := new(bytes.Buffer)
+				return &PooledWriter{Buf: buf, Writer: bare.NewWriter(buf)}
+			},
+		},
+	}
+}
+
+// Get returns a PooledWriter with its buffer reset and ready to write.
+func (p *WriterPool) Get() *PooledWriter {
+	pw := p.pool.Get().(*PooledWriter)
+	pw.Buf.Reset()
+	return pw
+}
+
+// Put returns pw to the pool for reuse. Callers must not use pw afterwards.
+func (p *WriterPool) Put(pw *PooledWriter) {
+	p.pool.Put(pw)
+}
+
+// MarshalAppend bare-encodes v and appends the result to dst, returning the
+// extended slice. It wraps dst in a bytes.Buffer, so the encoding lands in
+// dst's spare capacity instead of a fresh backing array when the caller
+// already owns a reusable/pre-sized slice; that bytes.Buffer is itself still
+// allocated on every call. It goes through MarshalValueWriter rather than
+// bare.MarshalWriter directly so types relying on the chunk0-1
+// BinaryMarshaler escape hatch (e.g. a time.Time field) still round-trip
+// with MarshalValue/UnmarshalValue instead of encoding their unexported
+// fields straight off the wire.
+func MarshalAppend(dst []byte, v interface{}) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w := bare.NewWriter(buf)
+	if err := MarshalValueWriter(w, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sizePlan is the cached, per-type shape of a struct: which fields are
+// fixed-width and which need their runtime value inspected (strings, data,
+// pointers/optionals, nested structs) to know their encoded size.
+type sizePlan struct {
+	fixed      int
+	varFields  []int
+	fieldPlans []*sizePlan // parallel to varFields, set for nested struct fields
+	binary     []bool      // parallel to varFields; true if the field must be
+	// sized from its encoding.BinaryMarshaler output (the MarshalValue/
+	// MarshalAppend shadow) rather than reflected into directly
+}
+
+var sizePlanCache sync.Map // reflect.Type -> *sizePlan
+
+var fixedWidth = map[reflect.Kind]int{
+	reflect.Bool:    1,
+	reflect.Int8:    1,
+	reflect.Uint8:   1,
+	reflect.Int16:   2,
+	reflect.Uint16:  2,
+	reflect.Int32:   4,
+	reflect.Uint32:  4,
+	reflect.Float32: 4,
+	reflect.Int64:   8,
+	reflect.Uint64:  8,
+	reflect.Float64: 8,
+}
+
+func planFor(t reflect.Type) *sizePlan {
+	if cached, ok := sizePlanCache.Load(t); ok {
+		return cached.(*sizePlan)
+	}
+
+	plan := &sizePlan{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		// A field routed through the BinaryMarshaler shadow in
+		// analyzeBinaryFields (marshaler.go) is written as a BARE data block
+		// of its MarshalBinary() output, not encoded from its own fields —
+		// walking into e.g. time.Time's internal wall/ext/loc fields would
+		// both size the wrong bytes and may not even match in count.
+		if f.Type.Implements(binaryMarshalerType) && reflect.PtrTo(f.Type).Implements(binaryUnmarshalerType) {
+			plan.varFields = append(plan.varFields, i)
+			plan.fieldPlans = append(plan.fieldPlans, nil)
+			plan.binary = append(plan.binary, true)
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if width, ok := fixedWidth[ft.Kind()]; ok && f.Type.Kind() != reflect.Ptr {
+			plan.fixed += width
+			continue
+		}
+		plan.varFields = append(plan.varFields, i)
+		plan.binary = append(plan.binary, false)
+		if ft.Kind() == reflect.Struct {
+			plan.fieldPlans = append(plan.fieldPlans, planFor(ft))
+		} else {
+			plan.fieldPlans = append(plan.fieldPlans, nil)
+		}
+	}
+
+	actual, _ := sizePlanCache.LoadOrStore(t, plan)
+	return actual.(*sizePlan)
+}
+
+// SizeOf estimates the encoded BARE size of v so a caller can
+// buf.Grow(SizeOf(v)) once instead of letting the buffer grow repeatedly.
+// The struct's fixed-width fields are costed from a cached per-type plan;
+// variable-width fields (strings, data, uint/int varints, pointers, nested
+// structs) are walked once per call since their size depends on the value.
+func SizeOf(v interface{}) (int, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return 0, nil
+	}
+	return sizeOfStruct(rv, planFor(rv.Type()))
+}
+
+func sizeOfStruct(rv reflect.Value, plan *sizePlan) (int, error) {
+	size := plan.fixed
+	for idx, i := range plan.varFields {
+		fv := rv.Field(i)
+		var (
+			n   int
+			err error
+		)
+		if plan.binary[idx] {
+			n, err = sizeOfBinaryField(fv)
+		} else {
+			n, err = sizeOfField(fv, plan.fieldPlans[idx])
+		}
+		if err != nil {
+			return 0, err
+		}
+		size += n
+	}
+	return size, nil
+}
+
+// sizeOfBinaryField sizes a field routed through the BinaryMarshaler shadow
+// as the BARE data block MarshalValue/MarshalAppend actually write: a
+// length-prefixed MarshalBinary() output, matching bare's own []byte
+// encoding (varintLen(len) + len).
+func sizeOfBinaryField(fv reflect.Value) (int, error) {
+	data, err := fv.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	return varintLen(uint64(len(data))) + len(data), nil
+}
+
+func sizeOfField(fv reflect.Value, nested *sizePlan) (int, error) {
+	switch fv.Kind() {
+	case reflect.String:
+		return varintLen(uint64(fv.Len())) + fv.Len(), nil
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			return varintLen(uint64(fv.Len())) + fv.Len(), nil
+		}
+		size := varintLen(uint64(fv.Len()))
+		for i := 0; i < fv.Len(); i++ {
+			n, err := sizeOfField(fv.Index(i), nested)
+			if err != nil {
+				return 0, err
+			}
+			size += n
+		}
+		return size, nil
+	case reflect.Uint:
+		return varintLen(fv.Uint()), nil
+	case reflect.Int:
+		// bare's int is a zigzag-encoded varint (like protobuf's sint), not a
+		// raw uvarint, so a negative value must be zigzag-mapped to uint64
+		// before varintLen sees it. fv.Uint() panics outright on a
+		// reflect.Int value; fv.Int() is the correct accessor.
+		return varintLen(zigzag(fv.Int())), nil
+	case reflect.Ptr:
+		if fv.IsNil() {
+			return 1, nil
+		}
+		if nested != nil {
+			n, err := sizeOfStruct(fv.Elem(), nested)
+			return 1 + n, err
+		}
+		n, err := sizeOfField(fv.Elem(), nested)
+		return 1 + n, err
+	case reflect.Struct:
+		if nested == nil {
+			nested = planFor(fv.Type())
+		}
+		return sizeOfStruct(fv, nested)
+	default:
+		if width, ok := fixedWidth[fv.Kind()]; ok {
+			return width, nil
+		}
+		return 0, nil
+	}
+}
+
+// varintLen returns the number of bytes bare's LEB128 uvarint encoding would
+// use for v.
+func varintLen(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
+
+// zigzag maps a signed int64 to the uint64 bare's varint-encoded int type
+// actually writes: small-magnitude negatives stay small after the mapping
+// instead of sign-extending to a near-2^64 uvarint.
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
@@ -0,0 +1,40 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+)
+
+// contextKey is an unexported type for keys stored in a Context, following
+// the convention from the context package's own documentation.
+type contextKey int
+
+const tokenContextKey contextKey = 0
+
+// WithToken returns a context carrying token, which RequireToken reads back
+// from the context of the call it intercepts. The token is not written into
+// the Envelope, so it stays on the side that set it: Server runs unary
+// handlers with context.Background(), and a RequireToken installed with
+// Server.Use never sees a token set by the client.
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenContextKey, token)
+}
+
+// TokenFromContext extracts the token previously stored by WithToken.
+func TokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(tokenContextKey).(string)
+	return token, ok
+}
+
+// RequireToken returns a UnaryInterceptor that rejects calls whose context
+// doesn't carry a token accepted by valid, e.g. one checking it against a
+// session's expiry the way a Session.Token would be validated on login.
+func RequireToken(valid func(token string) bool) UnaryInterceptor {
+	return func(ctx context.Context, method string, req interface{}, next UnaryHandler) (interface{}, error) {
+		token, ok := TokenFromContext(ctx)
+		if !ok || !valid(token) {
+			return nil, errors.New("rpc: missing or invalid token")
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,183 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"git.sr.ht/~sircmpwn/go-bare"
+)
+
+// Client is a BARE-RPC client multiplexing calls over a single connection.
+type Client struct {
+	conn io.ReadWriter
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *Envelope
+	closed  bool
+
+	// MaxFrameLength bounds the length prefix readLoop will accept before
+	// allocating a buffer for it, rejecting anything larger with
+	// ErrFrameTooLarge. Zero means defaultMaxFrameLength.
+	MaxFrameLength uint64
+
+	unary  []UnaryInterceptor
+	stream []StreamInterceptor
+}
+
+// NewClient wraps conn (already connected, e.g. a net.Conn) in a Client and
+// starts its background read loop.
+func NewClient(conn io.ReadWriter) *Client {
+	c := &Client{
+		conn:    conn,
+		pending: make(map[uint64]chan *Envelope),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Use appends unary interceptors to the client's chain, in call order.
+func (c *Client) Use(interceptors ...UnaryInterceptor) {
+	c.unary = append(c.unary, interceptors...)
+}
+
+// UseStream appends stream interceptors to the client's chain. Client.Stream
+// does not run this chain; stream interceptors run only on the Server side.
+func (c *Client) UseStream(interceptors ...StreamInterceptor) {
+	c.stream = append(c.stream, interceptors...)
+}
+
+func (c *Client) readLoop() {
+	r := bufio.NewReader(c.conn)
+	for {
+		env, err := readEnvelope(r, c.maxFrameLength())
+		if err != nil {
+			c.mu.Lock()
+			c.closed = true
+			pending := c.pending
+			c.pending = nil
+			c.mu.Unlock()
+
+			for _, ch := range pending {
+				close(ch)
+			}
+			return
+		}
+
+		c.mu.Lock()
+		ch := c.pending[env.ID]
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- env
+		}
+	}
+}
+
+func (c *Client) maxFrameLength() uint64 {
+	if c.MaxFrameLength == 0 {
+		return defaultMaxFrameLength
+	}
+	return c.MaxFrameLength
+}
+
+func (c *Client) allocID() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	return c.nextID
+}
+
+// register allocates a pending-reply channel for id. It fails once the
+// client's read loop has observed the connection close: without this check,
+// a register racing a connection drop would write into the nil map
+// readLoop leaves behind, panicking with "assignment to entry in nil map".
+func (c *Client) register(id uint64, buf int) (chan *Envelope, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, io.ErrClosedPipe
+	}
+	ch := make(chan *Envelope, buf)
+	c.pending[id] = ch
+	return ch, nil
+}
+
+func (c *Client) unregister(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending != nil {
+		delete(c.pending, id)
+	}
+}
+
+func (c *Client) writeEnvelope(env *Envelope) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return writeEnvelope(c.conn, env)
+}
+
+// Call performs a unary RPC: req is bare-encoded, sent to method, and the
+// response is decoded into resp. Both req and resp must be pointers.
+func (c *Client) Call(ctx context.Context, method string, req, resp interface{}) error {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return resp, c.call(ctx, method, req, resp)
+	}
+	_, err := chainUnary(method, c.unary, handler)(ctx, req)
+	return err
+}
+
+func (c *Client) call(ctx context.Context, method string, req, resp interface{}) error {
+	payload, err := bare.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	id := c.allocID()
+	ch, err := c.register(id, 1)
+	if err != nil {
+		return err
+	}
+	defer c.unregister(id)
+
+	if err := c.writeEnvelope(&Envelope{ID: id, Method: method, Kind: KindRequest, Payload: payload}); err != nil {
+		return err
+	}
+
+	select {
+	case env, ok := <-ch:
+		if !ok {
+			return io.ErrClosedPipe
+		}
+		if env.Kind == KindError {
+			return errors.New(string(env.Payload))
+		}
+		return bare.Unmarshal(env.Payload, resp)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stream opens a new client-stream/server-stream/bidi call to method. The
+// caller drives it with Stream.Send/Recv/Close.
+func (c *Client) Stream(ctx context.Context, method string) (*Stream, error) {
+	id := c.allocID()
+	ch, err := c.register(id, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	send := func(kind Kind, payload []byte) error {
+		return c.writeEnvelope(&Envelope{ID: id, Method: method, Kind: kind, Payload: payload})
+	}
+
+	if err := send(KindStreamOpen, nil); err != nil {
+		c.unregister(id)
+		return nil, err
+	}
+
+	return &Stream{id: id, method: method, send: send, recv: ch, cleanup: func() { c.unregister(id) }}, nil
+}
@@ -0,0 +1,34 @@
+// Package rpc layers a request/response and streaming RPC protocol on top of
+// BARE encoding, in the spirit of gRPC's four call types but without
+// depending on protobuf.
+package rpc
+
+// Kind distinguishes the purpose of an Envelope on the wire.
+type Kind uint8
+
+const (
+	KindRequest Kind = iota
+	KindResponse
+	KindStreamOpen
+	KindStreamMsg
+	KindStreamClose
+	KindError
+)
+
+// Envelope is the BARE-encoded frame exchanged between client and server:
+//
+//	type Envelope struct {
+//		id uint
+//		method string
+//		kind u8
+//		payload data
+//	}
+//
+// Each frame on the wire is a uint length prefix followed by the
+// bare-encoded Envelope.
+type Envelope struct {
+	ID      uint64
+	Method  string
+	Kind    Kind
+	Payload []byte
+}
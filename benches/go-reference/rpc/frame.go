@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"git.sr.ht/~sircmpwn/go-bare"
+)
+
+// defaultMaxFrameLength bounds the length prefix readEnvelope will accept
+// before allocating a buffer for it, when the caller hasn't configured a
+// tighter limit. This is the network-facing counterpart to the
+// MaxDataLength guard StreamReader (stream.go in the parent go-reference
+// package) uses for its own framing.
+const defaultMaxFrameLength = 16 << 20 // 16 MiB
+
+// ErrFrameTooLarge is returned by readEnvelope when a frame's length prefix
+// exceeds the configured limit, so callers can distinguish a hostile or
+// corrupt length prefix from an ordinary I/O error.
+var ErrFrameTooLarge = errors.New("rpc: frame length exceeds limit")
+
+// writeEnvelope bare-encodes env and writes it to w as a uvarint length
+// prefix followed by the encoded bytes.
+func writeEnvelope(w io.Writer, env *Envelope) error {
+	body, err := bare.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(body)))
+	if _, err := w.Write(lenBuf[:n]); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// readEnvelope reads one length-prefixed Envelope from r, rejecting a
+// length prefix larger than maxLen before allocating a buffer for it.
+func readEnvelope(r *bufio.Reader, maxLen uint64) (*Envelope, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if maxLen > 0 && length > maxLen {
+		return nil, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, length, maxLen)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	env := &Envelope{}
+	if err := bare.Unmarshal(body, env); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
@@ -0,0 +1,46 @@
+package rpc
+
+import "context"
+
+// UnaryHandler is the terminal step of a unary interceptor chain: it
+// performs the actual call (or, server-side, the actual handler
+// invocation) and returns the response.
+type UnaryHandler func(ctx context.Context, req interface{}) (interface{}, error)
+
+// UnaryInterceptor wraps a unary call with cross-cutting behavior such as
+// logging, auth, or metrics. Calling next continues the chain; returning
+// without calling next short-circuits it (e.g. to reject an unauthorized
+// call outright).
+type UnaryInterceptor func(ctx context.Context, method string, req interface{}, next UnaryHandler) (interface{}, error)
+
+// chainUnary composes interceptors into a single UnaryHandler, in the order
+// they were registered, terminating in handler.
+func chainUnary(method string, interceptors []UnaryInterceptor, handler UnaryHandler) UnaryHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor := interceptors[i]
+		next := handler
+		handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, method, req, next)
+		}
+	}
+	return handler
+}
+
+// StreamHandler is the terminal step of a stream interceptor chain.
+type StreamHandler func(ctx context.Context, method string, stream *Stream) error
+
+// StreamInterceptor wraps a server-side stream handler with cross-cutting
+// behavior, mirroring UnaryInterceptor. Client.Stream does not run stream
+// interceptors.
+type StreamInterceptor func(ctx context.Context, method string, stream *Stream, next StreamHandler) error
+
+func chainStream(interceptors []StreamInterceptor, handler StreamHandler) StreamHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor := interceptors[i]
+		next := handler
+		handler = func(ctx context.Context, method string, stream *Stream) error {
+			return interceptor(ctx, method, stream, next)
+		}
+	}
+	return handler
+}
@@ -0,0 +1,179 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+type echoMsg struct {
+	Msg string
+}
+
+func TestUnaryCallRoundTrip(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	srv := NewServer()
+	if err := srv.Register("echo", func(ctx context.Context, req *echoMsg) (*echoMsg, error) {
+		return &echoMsg{Msg: "echo:" + req.Msg}, nil
+	}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	go srv.Serve(serverConn)
+
+	client := NewClient(clientConn)
+	var resp echoMsg
+	if err := client.Call(context.Background(), "echo", &echoMsg{Msg: "hi"}, &resp); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if resp.Msg != "echo:hi" {
+		t.Errorf("resp.Msg = %q, want %q", resp.Msg, "echo:hi")
+	}
+}
+
+func TestUnaryCallUnknownMethod(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	srv := NewServer()
+	go srv.Serve(serverConn)
+
+	client := NewClient(clientConn)
+	var resp echoMsg
+	err := client.Call(context.Background(), "nope", &echoMsg{Msg: "hi"}, &resp)
+	if err == nil {
+		t.Fatal("Call to unknown method: want error, got nil")
+	}
+}
+
+func TestStreamRoundTripAndCleanup(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	srv := NewServer()
+	srv.RegisterStream("stream-echo", func(ctx context.Context, stream *Stream) error {
+		var msg echoMsg
+		for {
+			if err := stream.Recv(&msg); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+			if err := stream.Send(&msg); err != nil {
+				return err
+			}
+		}
+	})
+	go srv.Serve(serverConn)
+
+	client := NewClient(clientConn)
+	stream, err := client.Stream(context.Background(), "stream-echo")
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+
+	if err := stream.Send(&echoMsg{Msg: "ping"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	var out echoMsg
+	if err := stream.Recv(&out); err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if out.Msg != "ping" {
+		t.Errorf("Recv = %q, want %q", out.Msg, "ping")
+	}
+
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The Close above must release the client's pending-reply map entry for
+	// this stream; before the chunk0-3 cleanup fix, Client.Stream registered
+	// an entry that nothing ever removed.
+	client.mu.Lock()
+	n := len(client.pending)
+	client.mu.Unlock()
+	if n != 0 {
+		t.Errorf("client.pending has %d entries after Close, want 0", n)
+	}
+}
+
+// TestClientRegisterAfterCloseReturnsError guards against the nil-map panic
+// a register() racing readLoop's connection-drop teardown used to hit:
+// readLoop nils c.pending on error, so register must refuse to write to it
+// instead of panicking with "assignment to entry in nil map".
+func TestClientRegisterAfterCloseReturnsError(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	serverConn.Close()
+
+	client := NewClient(clientConn)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		client.mu.Lock()
+		closed := client.closed
+		client.mu.Unlock()
+		if closed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := client.register(1, 1); err == nil {
+		t.Fatal("register after readLoop closed: want error, got nil")
+	}
+}
+
+// TestRegisterRejectsNonPointerRequest guards against the panic
+// reflect.New(fn.Type().In(1).Elem()) used to hit inside dispatchUnary's
+// unrecovered goroutine when a handler's request parameter wasn't a
+// pointer: that crashed the whole server on the first call, instead of
+// Register simply refusing the bad handler shape up front.
+func TestRegisterRejectsNonPointerRequest(t *testing.T) {
+	srv := NewServer()
+	err := srv.Register("bad", func(ctx context.Context, req echoMsg) (*echoMsg, error) {
+		return &req, nil
+	})
+	if err == nil {
+		t.Fatal("Register with non-pointer request: want error, got nil")
+	}
+}
+
+func TestRegisterRejectsNonPointerResponse(t *testing.T) {
+	srv := NewServer()
+	err := srv.Register("bad", func(ctx context.Context, req *echoMsg) (echoMsg, error) {
+		return *req, nil
+	})
+	if err == nil {
+		t.Fatal("Register with non-pointer response: want error, got nil")
+	}
+}
+
+func TestRequireTokenInterceptorRejectsMissingToken(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	srv := NewServer()
+	srv.Use(RequireToken(func(token string) bool { return token == "secret" }))
+	srv.Register("echo", func(ctx context.Context, req *echoMsg) (*echoMsg, error) {
+		return req, nil
+	})
+	go srv.Serve(serverConn)
+
+	client := NewClient(clientConn)
+
+	var resp echoMsg
+	if err := client.Call(context.Background(), "echo", &echoMsg{Msg: "hi"}, &resp); err == nil {
+		t.Fatal("Call without token: want error, got nil")
+	}
+}
@@ -0,0 +1,211 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"git.sr.ht/~sircmpwn/go-bare"
+)
+
+// StreamHandlerFunc handles one open stream on the server side.
+type StreamHandlerFunc func(ctx context.Context, stream *Stream) error
+
+// Server dispatches incoming Envelopes to registered handlers.
+type Server struct {
+	mu          sync.Mutex
+	unaryFuncs  map[string]reflect.Value
+	streamFuncs map[string]StreamHandlerFunc
+
+	// MaxFrameLength bounds the length prefix Serve will accept before
+	// allocating a buffer for it, rejecting anything larger with
+	// ErrFrameTooLarge. Zero means defaultMaxFrameLength.
+	MaxFrameLength uint64
+
+	unary  []UnaryInterceptor
+	stream []StreamInterceptor
+}
+
+// NewServer returns an empty Server ready for Register calls.
+func NewServer() *Server {
+	return &Server{
+		unaryFuncs:  make(map[string]reflect.Value),
+		streamFuncs: make(map[string]StreamHandlerFunc),
+	}
+}
+
+// Use appends unary interceptors to the server's chain, in call order.
+func (s *Server) Use(interceptors ...UnaryInterceptor) {
+	s.unary = append(s.unary, interceptors...)
+}
+
+// UseStream appends stream interceptors to the server's chain.
+func (s *Server) UseStream(interceptors ...StreamInterceptor) {
+	s.stream = append(s.stream, interceptors...)
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Register binds name to handler, which must have the shape
+// func(context.Context, *Req) (*Resp, error) where Req and Resp are
+// BARE-encodable via bare.Marshal/bare.Unmarshal. The shape is validated
+// here, at registration time, rather than left for dispatchUnary to
+// discover: reflect.New(fn.Type().In(1).Elem()) panics on a non-pointer
+// request type, and that panic would happen inside the unrecovered
+// goroutine Serve spawns per call, crashing the whole server on the first
+// request to a badly registered method instead of failing the Register call.
+func (s *Server) Register(name string, handler interface{}) error {
+	fn := reflect.ValueOf(handler)
+	t := fn.Type()
+	if t.Kind() != reflect.Func ||
+		t.NumIn() != 2 || t.NumOut() != 2 ||
+		!t.In(0).Implements(contextType) ||
+		t.In(1).Kind() != reflect.Ptr ||
+		t.Out(0).Kind() != reflect.Ptr ||
+		!t.Out(1).Implements(errorType) {
+		return fmt.Errorf("rpc: handler for %q must be func(context.Context, *Req) (*Resp, error)", name)
+	}
+
+	s.mu.Lock()
+	s.unaryFuncs[name] = fn
+	s.mu.Unlock()
+	return nil
+}
+
+// RegisterStream binds name to a streaming handler.
+func (s *Server) RegisterStream(name string, handler StreamHandlerFunc) {
+	s.mu.Lock()
+	s.streamFuncs[name] = handler
+	s.mu.Unlock()
+}
+
+// Serve reads Envelopes from conn until it is closed or errors, dispatching
+// each to its registered handler on its own goroutine.
+func (s *Server) Serve(conn io.ReadWriter) error {
+	r := bufio.NewReader(conn)
+	var writeMu sync.Mutex
+	streams := make(map[uint64]chan *Envelope)
+	var streamsMu sync.Mutex
+
+	maxLen := s.MaxFrameLength
+	if maxLen == 0 {
+		maxLen = defaultMaxFrameLength
+	}
+
+	for {
+		env, err := readEnvelope(r, maxLen)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		switch env.Kind {
+		case KindRequest:
+			go s.dispatchUnary(conn, &writeMu, env)
+		case KindStreamOpen:
+			ch := make(chan *Envelope, 8)
+			streamsMu.Lock()
+			streams[env.ID] = ch
+			streamsMu.Unlock()
+
+			// dispatchStream owns ch for the life of the call; remove it
+			// from streams once the handler returns so a completed or
+			// abandoned stream doesn't leak a map entry and channel for
+			// the rest of the connection's lifetime.
+			go func(env *Envelope, ch chan *Envelope) {
+				defer func() {
+					streamsMu.Lock()
+					delete(streams, env.ID)
+					streamsMu.Unlock()
+				}()
+				s.dispatchStream(conn, &writeMu, env, ch)
+			}(env, ch)
+		case KindStreamMsg, KindStreamClose:
+			streamsMu.Lock()
+			ch := streams[env.ID]
+			streamsMu.Unlock()
+			if ch != nil {
+				ch <- env
+			}
+		}
+	}
+}
+
+func (s *Server) dispatchUnary(conn io.Writer, writeMu *sync.Mutex, env *Envelope) {
+	ctx := context.Background()
+
+	s.mu.Lock()
+	fn, ok := s.unaryFuncs[env.Method]
+	s.mu.Unlock()
+	if !ok {
+		s.reply(conn, writeMu, env.ID, KindError, []byte("rpc: unknown method "+env.Method))
+		return
+	}
+
+	reqPtr := reflect.New(fn.Type().In(1).Elem())
+	if err := bare.Unmarshal(env.Payload, reqPtr.Interface()); err != nil {
+		s.reply(conn, writeMu, env.ID, KindError, []byte(err.Error()))
+		return
+	}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		out := fn.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(req)})
+		if errVal, _ := out[1].Interface().(error); errVal != nil {
+			return nil, errVal
+		}
+		return out[0].Interface(), nil
+	}
+
+	resp, err := chainUnary(env.Method, s.unary, handler)(ctx, reqPtr.Interface())
+	if err != nil {
+		s.reply(conn, writeMu, env.ID, KindError, []byte(err.Error()))
+		return
+	}
+
+	payload, err := bare.Marshal(resp)
+	if err != nil {
+		s.reply(conn, writeMu, env.ID, KindError, []byte(err.Error()))
+		return
+	}
+	s.reply(conn, writeMu, env.ID, KindResponse, payload)
+}
+
+func (s *Server) dispatchStream(conn io.Writer, writeMu *sync.Mutex, open *Envelope, ch chan *Envelope) {
+	ctx := context.Background()
+
+	s.mu.Lock()
+	handler, ok := s.streamFuncs[open.Method]
+	s.mu.Unlock()
+	if !ok {
+		s.reply(conn, writeMu, open.ID, KindError, []byte("rpc: unknown stream method "+open.Method))
+		return
+	}
+
+	send := func(kind Kind, payload []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return writeEnvelope(conn, &Envelope{ID: open.ID, Method: open.Method, Kind: kind, Payload: payload})
+	}
+	stream := &Stream{id: open.ID, method: open.Method, send: send, recv: ch}
+
+	wrapped := func(ctx context.Context, method string, stream *Stream) error {
+		return handler(ctx, stream)
+	}
+	if err := chainStream(s.stream, wrapped)(ctx, open.Method, stream); err != nil {
+		s.reply(conn, writeMu, open.ID, KindError, []byte(err.Error()))
+	}
+}
+
+func (s *Server) reply(conn io.Writer, writeMu *sync.Mutex, id uint64, kind Kind, payload []byte) {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	_ = writeEnvelope(conn, &Envelope{ID: id, Kind: kind, Payload: payload})
+}
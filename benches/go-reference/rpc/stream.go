@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"git.sr.ht/~sircmpwn/go-bare"
+)
+
+// Stream is a bidirectional sequence of BARE-encoded messages belonging to
+// one call ID, used for server-stream, client-stream, and bidi RPCs.
+type Stream struct {
+	id     uint64
+	method string
+	send   func(kind Kind, payload []byte) error
+	recv   <-chan *Envelope
+
+	// cleanup, if set, releases the resources (pending map entry, channel)
+	// the owner allocated for this stream. It runs at most once, the first
+	// time the stream is observed to be done: Close, a received
+	// KindStreamClose/KindError, or the receive channel being closed.
+	cleanup     func()
+	cleanupOnce sync.Once
+}
+
+func (s *Stream) doCleanup() {
+	if s.cleanup != nil {
+		s.cleanupOnce.Do(s.cleanup)
+	}
+}
+
+// Method returns the RPC method this stream was opened for.
+func (s *Stream) Method() string { return s.method }
+
+// Send bare-encodes v and writes it as a stream message.
+func (s *Stream) Send(v interface{}) error {
+	payload, err := bare.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.send(KindStreamMsg, payload)
+}
+
+// Recv blocks for the next message on the stream and decodes it into v. It
+// returns io.EOF once the peer closes the stream.
+func (s *Stream) Recv(v interface{}) error {
+	env, ok := <-s.recv
+	if !ok {
+		s.doCleanup()
+		return io.ErrClosedPipe
+	}
+	switch env.Kind {
+	case KindStreamClose:
+		s.doCleanup()
+		return io.EOF
+	case KindError:
+		s.doCleanup()
+		return errors.New(string(env.Payload))
+	default:
+		return bare.Unmarshal(env.Payload, v)
+	}
+}
+
+// Close sends a stream-close frame, signalling the peer that no more
+// messages will follow.
+func (s *Stream) Close() error {
+	defer s.doCleanup()
+	return s.send(KindStreamClose, nil)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"git.sr.ht/~sircmpwn/go-bare"
+)
+
+// ErrLimitExceeded is returned by StreamReader when an incoming length
+// prefix exceeds MaxDataLength, so callers can tell a hostile/corrupt
+// length prefix apart from an ordinary I/O error.
+var ErrLimitExceeded = errors.New("bare: length prefix exceeds configured limit")
+
+// StreamReader decodes a sequence of uvarint-length-prefixed BARE messages
+// from an io.Reader one at a time, so a caller never has to buffer an
+// entire log file or network session in memory to read it. This is a
+// framing convention layered on top of bare.Unmarshal, not a change to
+// bare.Reader itself: each frame must have been written with a length
+// prefix (see rpc.writeEnvelope for the same convention).
+type StreamReader struct {
+	r *bufio.Reader
+
+	// MaxDataLength rejects a frame whose length prefix exceeds it before
+	// allocating a buffer for it. Zero means unlimited.
+	MaxDataLength int
+
+	// MaxArrayLength bounds the element count of array/data fields inside
+	// the decoded payload itself, as opposed to MaxDataLength above (which
+	// only bounds this package's own outer frame-length prefix and says
+	// nothing about a length-prefixed field nested inside that frame).
+	// go-bare already defaults this to 4096 elements on its own, so the
+	// adversarial-allocation case is covered even when this is left zero;
+	// set it only to use a different limit than that default. It maps onto
+	// bare.MaxArrayLength, which is a process-wide setting in the underlying
+	// library, not scoped to this StreamReader — avoid setting it from more
+	// than one place in a process.
+	MaxArrayLength int
+}
+
+// NewStreamReader wraps r for incremental decoding.
+func NewStreamReader(r io.Reader) *StreamReader {
+	return &StreamReader{r: bufio.NewReader(r)}
+}
+
+// Decode reads exactly one length-prefixed frame from the StreamReader's
+// buffered input and decodes it into v via UnmarshalValue (not
+// bare.Unmarshal directly). The io.Reader given to NewStreamReader is read
+// through a bufio.Reader, so it may be read past the end of the frame; the
+// bytes read ahead are kept for the next Decode. Going through UnmarshalValue keeps
+// this in sync with the chunk0-1 BinaryMarshaler escape hatch: a field like
+// time.Time that MarshalValue shadowed out to a BARE data block needs the
+// same shadow-aware path to unmarshal back, or it panics reflecting into its
+// own unexported fields.
+func (sr *StreamReader) Decode(v interface{}) error {
+	if sr.MaxArrayLength > 0 {
+		bare.MaxArrayLength(uint64(sr.MaxArrayLength))
+	}
+
+	length, err := binary.ReadUvarint(sr.r)
+	if err != nil {
+		return err
+	}
+	if sr.MaxDataLength > 0 && length > uint64(sr.MaxDataLength) {
+		return ErrLimitExceeded
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(sr.r, body); err != nil {
+		return err
+	}
+	return UnmarshalValue(body, v)
+}
+
+// DecodeStream repeatedly decodes into elem and calls yield, until the
+// underlying reader is exhausted (io.EOF) or either Decode or yield
+// returns an error. elem is reused across iterations, so yield must finish
+// with it (or copy out what it needs) before returning.
+func (sr *StreamReader) DecodeStream(elem interface{}, yield func() error) error {
+	for {
+		if err := sr.Decode(elem); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := yield(); err != nil {
+			return err
+		}
+	}
+}
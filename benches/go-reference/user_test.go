@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"git.sr.ht/~sircmpwn/go-bare"
 	"log"
 	"testing"
+	"time"
 )
 
 type UserRole uint
@@ -21,11 +24,12 @@ type Session struct {
 }
 
 type User struct {
-	ID      uint
-	Name    string
-	Email   string
-	Role    UserRole
-	Session *Session
+	ID        uint
+	Name      string
+	Email     string
+	Role      UserRole
+	Session   *Session
+	CreatedAt time.Time
 }
 
 func makeAdmin() ([]byte, User) {
@@ -35,14 +39,15 @@ func makeAdmin() ([]byte, User) {
 	}
 
 	admin := User{
-		ID:      42,
-		Name:    "Jane Doe",
-		Email:   "jdoe@example.com",
-		Role:    Admin,
-		Session: &session,
+		ID:        42,
+		Name:      "Jane Doe",
+		Email:     "jdoe@example.com",
+		Role:      Admin,
+		Session:   &session,
+		CreatedAt: time.Date(2021, time.March, 4, 12, 0, 0, 0, time.UTC),
 	}
 
-	marshalled, err := bare.Marshal(&admin)
+	marshalled, err := MarshalValue(&admin)
 
 	if err != nil {
 		log.Fatalf("Failed to marshal: %s", err)
@@ -53,14 +58,15 @@ func makeAdmin() ([]byte, User) {
 
 func makeGuest() ([]byte, User) {
 	guest := User{
-		ID:      112,
-		Name:    "John Smith",
-		Email:   "john@example.com",
-		Role:    Guest,
-		Session: nil,
+		ID:        112,
+		Name:      "John Smith",
+		Email:     "john@example.com",
+		Role:      Guest,
+		Session:   nil,
+		CreatedAt: time.Date(2021, time.March, 4, 12, 0, 0, 0, time.UTC),
 	}
 
-	marshalled, err := bare.Marshal(&guest)
+	marshalled, err := MarshalValue(&guest)
 
 	if err != nil {
 		log.Fatalf("Failed to marshal: %s", err)
@@ -78,11 +84,36 @@ func BenchmarkAdminSerialize(b *testing.B) {
 	b.SetBytes(int64(len(s)))
 	for n := 0; n < b.N; n++ {
 		w := bare.NewWriter(&buf)
-		bare.MarshalWriter(w, admin)
+		MarshalValueWriter(w, admin)
 		buf.Reset()
 	}
 }
 
+func BenchmarkAdminSerializePooled(b *testing.B) {
+	s, admin := makeAdmin()
+	pool := NewWriterPool()
+
+	b.ResetTimer()
+	b.SetBytes(int64(len(s)))
+	for n := 0; n < b.N; n++ {
+		pw := pool.Get()
+		MarshalValueWriter(pw.Writer, admin)
+		pool.Put(pw)
+	}
+}
+
+func BenchmarkAdminSerializeAppend(b *testing.B) {
+	s, admin := makeAdmin()
+	size, _ := SizeOf(admin)
+	dst := make([]byte, 0, size)
+
+	b.ResetTimer()
+	b.SetBytes(int64(len(s)))
+	for n := 0; n < b.N; n++ {
+		_, _ = MarshalAppend(dst, admin)
+	}
+}
+
 func BenchmarkAdminDeserialize(b *testing.B) {
 	s, _ := makeAdmin()
 	var output User
@@ -90,7 +121,7 @@ func BenchmarkAdminDeserialize(b *testing.B) {
 	b.ResetTimer()
 	b.SetBytes(int64(len(s)))
 	for n := 0; n < b.N; n++ {
-		_ = bare.Unmarshal(s, &output)
+		_ = UnmarshalValue(s, &output)
 	}
 }
 
@@ -103,7 +134,7 @@ func BenchmarkGuestSerialize(b *testing.B) {
 	b.SetBytes(int64(len(s)))
 	for n := 0; n < b.N; n++ {
 		w := bare.NewWriter(&buf)
-		bare.MarshalWriter(w, guest)
+		MarshalValueWriter(w, guest)
 		buf.Reset()
 	}
 }
@@ -115,6 +146,268 @@ func BenchmarkGuestDeserialize(b *testing.B) {
 	b.ResetTimer()
 	b.SetBytes(int64(len(s)))
 	for n := 0; n < b.N; n++ {
-		_ = bare.Unmarshal(s, &output)
+		_ = UnmarshalValue(s, &output)
+	}
+}
+
+func TestMarshalUnmarshalValueRoundTrip(t *testing.T) {
+	_, admin := makeAdmin()
+
+	data, err := MarshalValue(&admin)
+	if err != nil {
+		t.Fatalf("MarshalValue: %v", err)
+	}
+
+	var out User
+	if err := UnmarshalValue(data, &out); err != nil {
+		t.Fatalf("UnmarshalValue: %v", err)
+	}
+
+	if out.ID != admin.ID || out.Name != admin.Name || out.Email != admin.Email || out.Role != admin.Role {
+		t.Errorf("round-tripped scalar fields = %+v, want %+v", out, admin)
+	}
+	if out.Session == nil || out.Session.Expires != admin.Session.Expires || !bytes.Equal(out.Session.Token, admin.Session.Token) {
+		t.Errorf("round-tripped Session = %+v, want %+v", out.Session, admin.Session)
+	}
+	if !out.CreatedAt.Equal(admin.CreatedAt) {
+		t.Errorf("round-tripped CreatedAt = %v, want %v", out.CreatedAt, admin.CreatedAt)
+	}
+}
+
+func TestMarshalUnmarshalValueRoundTripNilSession(t *testing.T) {
+	_, guest := makeGuest()
+
+	data, err := MarshalValue(&guest)
+	if err != nil {
+		t.Fatalf("MarshalValue: %v", err)
+	}
+
+	var out User
+	if err := UnmarshalValue(data, &out); err != nil {
+		t.Fatalf("UnmarshalValue: %v", err)
+	}
+	if out.Session != nil {
+		t.Errorf("Session = %+v, want nil", out.Session)
+	}
+	if !out.CreatedAt.Equal(guest.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want %v", out.CreatedAt, guest.CreatedAt)
+	}
+}
+
+// TestStreamReaderDecodesTimeField guards against StreamReader.Decode
+// reverting to calling bare.Unmarshal directly: that panics decoding
+// CreatedAt, since the shadowed BARE data block for a BinaryMarshaler field
+// only reconstitutes correctly through UnmarshalValue.
+func TestStreamReaderDecodesTimeField(t *testing.T) {
+	s, admin := makeAdmin()
+
+	var frame bytes.Buffer
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(s)))
+	frame.Write(lenBuf[:n])
+	frame.Write(s)
+
+	var out User
+	sr := NewStreamReader(bytes.NewReader(frame.Bytes()))
+	if err := sr.Decode(&out); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if out.Name != admin.Name {
+		t.Errorf("Name = %q, want %q", out.Name, admin.Name)
+	}
+	if !out.CreatedAt.Equal(admin.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want %v", out.CreatedAt, admin.CreatedAt)
+	}
+}
+
+func TestStreamReaderDecodeStream(t *testing.T) {
+	adminBytes, admin := makeAdmin()
+	guestBytes, guest := makeGuest()
+
+	var frame bytes.Buffer
+	for _, s := range [][]byte{adminBytes, guestBytes} {
+		var lenBuf [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(lenBuf[:], uint64(len(s)))
+		frame.Write(lenBuf[:n])
+		frame.Write(s)
+	}
+
+	want := []User{admin, guest}
+	var got []User
+	var elem User
+	sr := NewStreamReader(bytes.NewReader(frame.Bytes()))
+	err := sr.DecodeStream(&elem, func() error {
+		got = append(got, elem)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DecodeStream: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("decoded %d users, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || !got[i].CreatedAt.Equal(want[i].CreatedAt) {
+			t.Errorf("user %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestStreamReaderDecodeRejectsOversizedFrame guards against a hostile outer
+// length prefix being allocated for before any decoding happens.
+func TestStreamReaderDecodeRejectsOversizedFrame(t *testing.T) {
+	s, _ := makeAdmin()
+
+	var frame bytes.Buffer
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(s)))
+	frame.Write(lenBuf[:n])
+	frame.Write(s)
+
+	var out User
+	sr := NewStreamReader(bytes.NewReader(frame.Bytes()))
+	sr.MaxDataLength = len(s) - 1
+	if err := sr.Decode(&out); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Decode with frame over MaxDataLength: err = %v, want ErrLimitExceeded", err)
+	}
+}
+
+// TestStreamReaderMaxArrayLengthRejectsOversizedArray guards against
+// StreamReader.MaxArrayLength being dead configuration. MaxDataLength alone
+// only bounds this package's own outer frame-length prefix; the adversarial
+// allocation this is meant to stop comes from an array/data length prefix
+// nested inside an otherwise small frame, which MaxArrayLength must reach by
+// tightening go-bare's own package-wide bare.MaxArrayLength limit.
+func TestStreamReaderMaxArrayLengthRejectsOversizedArray(t *testing.T) {
+	defer bare.MaxArrayLength(4096) // restore go-bare's own default
+
+	type Tags struct {
+		Values []uint64
+	}
+	big := Tags{Values: make([]uint64, 10)}
+	data, err := MarshalValue(&big)
+	if err != nil {
+		t.Fatalf("MarshalValue: %v", err)
+	}
+
+	var frame bytes.Buffer
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(data)))
+	frame.Write(lenBuf[:n])
+	frame.Write(data)
+
+	var out Tags
+	sr := NewStreamReader(bytes.NewReader(frame.Bytes()))
+	sr.MaxArrayLength = 5
+	if err := sr.Decode(&out); err == nil {
+		t.Fatal("Decode with array over MaxArrayLength: want error, got nil")
+	}
+}
+
+func BenchmarkAdminDeserializeStream(b *testing.B) {
+	s, _ := makeAdmin()
+
+	var frame bytes.Buffer
+	var lenBuf [binary.MaxVarintLen64]byte
+	prefixLen := binary.PutUvarint(lenBuf[:], uint64(len(s)))
+	frame.Write(lenBuf[:prefixLen])
+	frame.Write(s)
+	framed := frame.Bytes()
+
+	var output User
+	b.ResetTimer()
+	b.SetBytes(int64(len(s)))
+	for n := 0; n < b.N; n++ {
+		sr := NewStreamReader(bytes.NewReader(framed))
+		_ = sr.Decode(&output)
+	}
+}
+
+// TestMarshalAppendMatchesMarshalValue guards against MarshalAppend
+// bypassing the chunk0-1 BinaryMarshaler shadow: it used to call
+// bare.MarshalWriter directly, which for a User with CreatedAt set produces
+// wire-incompatible bytes versus MarshalValue for the same value.
+func TestMarshalAppendMatchesMarshalValue(t *testing.T) {
+	_, admin := makeAdmin()
+
+	want, err := MarshalValue(&admin)
+	if err != nil {
+		t.Fatalf("MarshalValue: %v", err)
+	}
+	got, err := MarshalAppend(nil, admin)
+	if err != nil {
+		t.Fatalf("MarshalAppend: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("MarshalAppend = %x, want %x", got, want)
+	}
+}
+
+// TestSizeOfPlainIntField guards against sizeOfField calling fv.Uint() on a
+// reflect.Int field, which panics, and against the zigzag mapping being off
+// at the single-byte/two-byte varint boundary for negative values.
+func TestSizeOfPlainIntField(t *testing.T) {
+	type S struct {
+		V int
+	}
+	cases := []struct {
+		v    int
+		want int
+	}{
+		{0, 1},
+		{63, 1},
+		{64, 2},
+		{-1, 1},
+		{-64, 1},
+		{-65, 2},
+	}
+	for _, c := range cases {
+		size, err := SizeOf(S{V: c.v})
+		if err != nil {
+			t.Fatalf("SizeOf(%d): %v", c.v, err)
+		}
+		if size != c.want {
+			t.Errorf("SizeOf(%d) = %d, want %d", c.v, size, c.want)
+		}
+	}
+}
+
+// TestSizeOfMatchesMarshalValueLength guards against SizeOf walking a
+// BinaryMarshaler field's own internal fields (e.g. time.Time's wall/ext/loc)
+// instead of sizing the MarshalBinary() output MarshalValue actually writes
+// for it; those two sizes diverge for CreatedAt.
+func TestSizeOfMatchesMarshalValueLength(t *testing.T) {
+	_, admin := makeAdmin()
+
+	size, err := SizeOf(admin)
+	if err != nil {
+		t.Fatalf("SizeOf: %v", err)
+	}
+	data, err := MarshalValue(&admin)
+	if err != nil {
+		t.Fatalf("MarshalValue: %v", err)
+	}
+	if size != len(data) {
+		t.Errorf("SizeOf(admin) = %d, want %d (len of MarshalValue output)", size, len(data))
+	}
+}
+
+func TestWriterPoolRoundTrip(t *testing.T) {
+	_, admin := makeAdmin()
+
+	pool := NewWriterPool()
+	pw := pool.Get()
+	if err := MarshalValueWriter(pw.Writer, admin); err != nil {
+		t.Fatalf("MarshalValueWriter: %v", err)
+	}
+	data := append([]byte(nil), pw.Buf.Bytes()...)
+	pool.Put(pw)
+
+	var out User
+	if err := UnmarshalValue(data, &out); err != nil {
+		t.Fatalf("UnmarshalValue: %v", err)
+	}
+	if out.Name != admin.Name || !out.CreatedAt.Equal(admin.CreatedAt) {
+		t.Errorf("round-tripped = %+v, want %+v", out, admin)
 	}
 }